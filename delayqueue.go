@@ -0,0 +1,97 @@
+package pqueue
+
+import (
+	"sync"
+	"time"
+)
+
+// DelayQueue is a queue that makes items available through Dequeue
+// only once their scheduled ready time has elapsed. It is modeled on
+// client-go's delaying workqueue: items are kept in the same min-heap
+// used by Queue, keyed on their ready time, and a timer is reset on
+// demand so Dequeue wakes up exactly when the next item is due.
+type DelayQueue[V any] struct {
+	queue   *Queue[int64, V] // priority is the ready time as UnixNano
+	lock    sync.Mutex
+	cond    *sync.Cond
+	stopped bool
+}
+
+// NewDelayQueue creates and initializes a new, empty delay queue.
+func NewDelayQueue[V any]() (dq *DelayQueue[V]) {
+	dq = &DelayQueue[V]{
+		queue: New[int64, V](0),
+	}
+	dq.cond = sync.NewCond(&dq.lock)
+	return
+}
+
+// AddAfter schedules item to become available once delay has elapsed.
+// It is a no-op if the queue has already been stopped.
+func (dq *DelayQueue[V]) AddAfter(item V, delay time.Duration) {
+	dq.lock.Lock()
+	defer dq.lock.Unlock()
+	if dq.stopped {
+		return
+	}
+	_, _ = dq.queue.Enqueue(item, time.Now().Add(delay).UnixNano())
+	dq.cond.Broadcast()
+}
+
+// Dequeue blocks until the earliest scheduled item becomes ready and
+// returns it, or returns ok == false once the queue has been stopped.
+func (dq *DelayQueue[V]) Dequeue() (item V, ok bool) {
+	dq.lock.Lock()
+	defer dq.lock.Unlock()
+	for {
+		if dq.stopped {
+			return
+		}
+		if dq.queue.Len() == 0 {
+			dq.cond.Wait()
+			continue
+		}
+		_, readyAt := dq.queue.Front()
+		wait := time.Until(time.Unix(0, readyAt))
+		if wait <= 0 {
+			item, _, _ = dq.queue.Dequeue()
+			return item, true
+		}
+		timer := time.AfterFunc(wait, func() {
+			dq.lock.Lock()
+			dq.cond.Broadcast()
+			dq.lock.Unlock()
+		})
+		dq.cond.Wait()
+		timer.Stop()
+	}
+}
+
+// Peek returns the ready time of the next item without removing it,
+// and false if the queue is empty.
+func (dq *DelayQueue[V]) Peek() (readyAt time.Time, ok bool) {
+	dq.lock.Lock()
+	defer dq.lock.Unlock()
+	if dq.queue.Len() == 0 {
+		return
+	}
+	_, nanos := dq.queue.Front()
+	return time.Unix(0, nanos), true
+}
+
+// Len returns the number of items currently scheduled, ready or not.
+func (dq *DelayQueue[V]) Len() int {
+	return dq.queue.Len()
+}
+
+// Stop shuts the queue down, waking every blocked Dequeue call. Further
+// calls to AddAfter are ignored and Dequeue returns ok == false.
+func (dq *DelayQueue[V]) Stop() {
+	dq.lock.Lock()
+	defer dq.lock.Unlock()
+	if dq.stopped {
+		return
+	}
+	dq.stopped = true
+	dq.cond.Broadcast()
+}
@@ -0,0 +1,61 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelayQueueAddAfter(t *testing.T) {
+	dq := NewDelayQueue[string]()
+	start := time.Now()
+	dq.AddAfter("late", 200*time.Millisecond)
+	dq.AddAfter("early", 10*time.Millisecond)
+	item, ok := dq.Dequeue()
+	if !ok || item != "early" {
+		t.Errorf("expected %q to become ready first, given %q", "early", item)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("expected Dequeue to wait for the item to become ready, waited %s", elapsed)
+	}
+	item, ok = dq.Dequeue()
+	if !ok || item != "late" {
+		t.Errorf("expected %q to become ready second, given %q", "late", item)
+	}
+}
+
+func TestDelayQueuePeekAndLen(t *testing.T) {
+	dq := NewDelayQueue[int]()
+	if _, ok := dq.Peek(); ok {
+		t.Errorf("expected empty queue to have nothing to peek")
+	}
+	dq.AddAfter(1, time.Hour)
+	if dq.Len() != 1 {
+		t.Errorf("expected queue to hold 1 item, given %d", dq.Len())
+	}
+	if _, ok := dq.Peek(); !ok {
+		t.Errorf("expected a ready time for the scheduled item")
+	}
+}
+
+func TestDelayQueueStop(t *testing.T) {
+	dq := NewDelayQueue[int]()
+	done := make(chan bool)
+	go func() {
+		_, ok := dq.Dequeue()
+		done <- ok
+	}()
+	<-time.After(50 * time.Millisecond)
+	dq.Stop()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Errorf("expected Dequeue to report the queue as stopped")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected Stop to wake a blocked Dequeue call")
+	}
+	dq.AddAfter(1, 0)
+	if dq.Len() != 0 {
+		t.Errorf("expected AddAfter to be a no-op after Stop")
+	}
+}
@@ -0,0 +1,202 @@
+package pqueue
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// PriorityFunc returns the current, exact priority of an item. It may
+// be expensive to evaluate, which is why LazyQueue tries to call it as
+// rarely as possible.
+type PriorityFunc[V any, P Ordered] func(item V) P
+
+// EstimateFunc returns an upper-bound estimate of an item's priority,
+// valid until the queue's next Refresh. It should be cheap to
+// evaluate; LazyQueue relies on it never under-estimating the true
+// priority returned by the matching PriorityFunc.
+type EstimateFunc[V any, P Ordered] func(item V) P
+
+// LazyItem is a handle to a value pushed onto a LazyQueue. It can be
+// passed to Update when a caller knows the item's cached estimate has
+// been exceeded.
+type LazyItem[V any, P Ordered] struct {
+	value    V
+	estimate P
+	index    int
+	owner    *lazySorter[V, P]
+}
+
+// LazyQueue is a priority queue for items whose priority drifts over
+// time (aging, decaying scores, and the like), inspired by
+// go-ethereum's common/prque/lazyqueue. Rather than pay for a full
+// re-heapify whenever an item's priority changes, it orders items by a
+// cheap upper-bound estimate and only calls the (potentially
+// expensive) exact priority function on the current best candidate in
+// Pop.
+type LazyQueue[V any, P Ordered] struct {
+	lock     sync.Mutex
+	exact    PriorityFunc[V, P]
+	estimate EstimateFunc[V, P]
+	// queue[0] holds items ordered by their estimate, an upper bound
+	// on the true priority that has not yet been confirmed.
+	// queue[1] holds items whose exact priority has already been
+	// computed this round but that were not (yet) the overall best.
+	queue [2]*lazySorter[V, P]
+}
+
+// NewLazyQueue creates a new, empty lazy queue. exact computes an
+// item's true priority; estimate computes a cheap upper bound on it
+// that holds until the next call to Refresh.
+func NewLazyQueue[V any, P Ordered](exact PriorityFunc[V, P], estimate EstimateFunc[V, P]) *LazyQueue[V, P] {
+	q := &LazyQueue[V, P]{exact: exact, estimate: estimate}
+	q.queue[0] = new(lazySorter[V, P])
+	q.queue[1] = new(lazySorter[V, P])
+	heap.Init(q.queue[0])
+	heap.Init(q.queue[1])
+	return q
+}
+
+// Push adds item to the queue under its current estimate, and returns
+// a handle that can later be passed to Update.
+func (q *LazyQueue[V, P]) Push(item V) *LazyItem[V, P] {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	li := &LazyItem[V, P]{value: item, estimate: q.estimate(item)}
+	heap.Push(q.queue[0], li)
+	return li
+}
+
+// Pop removes and returns the item with the highest exact priority,
+// and false if the queue is empty. It evaluates the exact priority
+// function only for candidates that could plausibly be the best,
+// using their estimates (and other items' already-confirmed exact
+// priorities) as upper bounds to rule the rest out.
+func (q *LazyQueue[V, P]) Pop() (item V, priority P, ok bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for {
+		top0 := q.queue[0].peek()
+		top1 := q.queue[1].peek()
+		if top0 == nil && top1 == nil {
+			return
+		}
+
+		if top0 == nil || (top1 != nil && top1.estimate > top0.estimate) {
+			// top1's confirmed priority already beats the best
+			// possible estimate left in queue[0]; it must be the
+			// overall winner.
+			popped := heap.Pop(q.queue[1]).(*LazyItem[V, P])
+			return popped.value, popped.estimate, true
+		}
+
+		// top0 holds the best remaining upper bound, but its true
+		// priority is not yet known; find out.
+		popped := heap.Pop(q.queue[0]).(*LazyItem[V, P])
+		exact := q.exact(popped.value)
+
+		if best := q.queue[0].peek(); best != nil && best.estimate > exact {
+			popped.estimate = exact
+			heap.Push(q.queue[1], popped)
+			continue
+		}
+		if top1 != nil && top1.estimate > exact {
+			popped.estimate = exact
+			heap.Push(q.queue[1], popped)
+			continue
+		}
+		return popped.value, exact, true
+	}
+}
+
+// Update recomputes the priority bound for a single item immediately,
+// for callers that know its cached bound no longer holds, rather than
+// waiting for the next Refresh. An item parked in queue[1] carries an
+// already-confirmed exact priority rather than an upper-bound
+// estimate, so it is the exact priority that gets recomputed there;
+// overwriting it with a fresh estimate would let Pop's fast path treat
+// an unverified bound as confirmed and return the wrong item.
+func (q *LazyQueue[V, P]) Update(item *LazyItem[V, P]) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if item.owner == nil {
+		return
+	}
+	if item.owner == q.queue[1] {
+		item.estimate = q.exact(item.value)
+	} else {
+		item.estimate = q.estimate(item.value)
+	}
+	heap.Fix(item.owner, item.index)
+}
+
+// Refresh re-evaluates every item's estimate for the upcoming window.
+// Call it periodically (once per tick, say) instead of calling Update
+// for every item whose priority has drifted.
+func (q *LazyQueue[V, P]) Refresh() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	items := make([]*LazyItem[V, P], 0, q.queue[0].Len()+q.queue[1].Len())
+	for q.queue[0].Len() > 0 {
+		items = append(items, heap.Pop(q.queue[0]).(*LazyItem[V, P]))
+	}
+	for q.queue[1].Len() > 0 {
+		items = append(items, heap.Pop(q.queue[1]).(*LazyItem[V, P]))
+	}
+	for _, item := range items {
+		item.estimate = q.estimate(item.value)
+		heap.Push(q.queue[0], item)
+	}
+}
+
+// Len returns the number of items currently held by the queue.
+func (q *LazyQueue[V, P]) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.queue[0].Len() + q.queue[1].Len()
+}
+
+type lazySorter[V any, P Ordered] []*LazyItem[V, P]
+
+func (s *lazySorter[V, P]) Push(i interface{}) {
+	item, ok := i.(*LazyItem[V, P])
+	if !ok {
+		return
+	}
+	item.index = len(*s)
+	item.owner = s
+	*s = append(*s, item)
+}
+
+func (s *lazySorter[V, P]) Pop() interface{} {
+	old := *s
+	n := len(old)
+	if n == 0 {
+		return nil
+	}
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	item.owner = nil
+	*s = old[0 : n-1]
+	return item
+}
+
+func (s lazySorter[V, P]) peek() *LazyItem[V, P] {
+	if len(s) == 0 {
+		return nil
+	}
+	return s[0]
+}
+
+func (s lazySorter[V, P]) Len() int { return len(s) }
+
+// Less orders items by descending estimate: the queue is a max-heap
+// on the upper-bound priority.
+func (s lazySorter[V, P]) Less(i, j int) bool { return s[i].estimate > s[j].estimate }
+
+func (s lazySorter[V, P]) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+	s[i].index = i
+	s[j].index = j
+}
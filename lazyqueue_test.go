@@ -0,0 +1,106 @@
+package pqueue
+
+import "testing"
+
+func TestLazyQueuePopOrdersByExactPriority(t *testing.T) {
+	exact := map[string]int{"a": 3, "b": 5, "c": 1}
+	// deliberately loose estimates so Pop must reconcile them against
+	// the exact priorities above
+	estimate := map[string]int{"a": 10, "b": 6, "c": 10}
+
+	q := NewLazyQueue[string, int](
+		func(item string) int { return exact[item] },
+		func(item string) int { return estimate[item] },
+	)
+	for _, item := range []string{"a", "b", "c"} {
+		q.Push(item)
+	}
+
+	for _, want := range []string{"b", "a", "c"} {
+		item, priority, ok := q.Pop()
+		if !ok || item != want {
+			t.Errorf("expected to pop %q, given %q", want, item)
+		}
+		if priority != exact[item] {
+			t.Errorf("expected exact priority %d for %q, given %d", exact[item], item, priority)
+		}
+	}
+	if _, _, ok := q.Pop(); ok {
+		t.Errorf("expected queue to be empty")
+	}
+}
+
+func TestLazyQueueUpdate(t *testing.T) {
+	exact := map[string]int{"a": 1, "b": 2}
+	estimate := map[string]int{"a": 1, "b": 2}
+
+	q := NewLazyQueue[string, int](
+		func(item string) int { return exact[item] },
+		func(item string) int { return estimate[item] },
+	)
+	handle := q.Push("a")
+	q.Push("b")
+
+	// "a"'s priority rose past its cached estimate; tell the queue
+	// before the true priority is ever read.
+	exact["a"] = 9
+	estimate["a"] = 9
+	q.Update(handle)
+
+	item, _, ok := q.Pop()
+	if !ok || item != "a" {
+		t.Errorf("expected updated item %q to pop first, given %q", "a", item)
+	}
+}
+
+func TestLazyQueueUpdateOnConfirmedItemRecomputesExact(t *testing.T) {
+	exact := map[string]int{"a": 1, "b": 5}
+	estimate := map[string]int{"a": 10, "b": 5}
+
+	q := NewLazyQueue[string, int](
+		func(item string) int { return exact[item] },
+		func(item string) int { return estimate[item] },
+	)
+	handle := q.Push("a")
+	q.Push("b")
+
+	// forces Pop to reconcile "a"'s loose estimate against its exact
+	// priority and park it in queue[1] before returning "b".
+	item, _, ok := q.Pop()
+	if !ok || item != "b" {
+		t.Fatalf("expected %q to pop first, given %q", "b", item)
+	}
+
+	// "a" now carries a confirmed exact priority, not an estimate;
+	// Update must recompute that exact priority rather than
+	// overwriting it with a fresh (and here, stale) estimate.
+	exact["a"] = 9
+	q.Update(handle)
+
+	_, priority, ok := q.Pop()
+	if !ok || priority != 9 {
+		t.Errorf("expected Update to recompute the confirmed exact priority to 9, given %d", priority)
+	}
+}
+
+func TestLazyQueueRefresh(t *testing.T) {
+	priority := map[string]int{"a": 1, "b": 2}
+
+	q := NewLazyQueue[string, int](
+		func(item string) int { return priority[item] },
+		func(item string) int { return priority[item] },
+	)
+	q.Push("a")
+	q.Push("b")
+
+	priority["a"] = 5
+	q.Refresh()
+
+	item, _, ok := q.Pop()
+	if !ok || item != "a" {
+		t.Errorf("expected Refresh to pick up the new priority for %q, given %q", "a", item)
+	}
+	if q.Len() != 1 {
+		t.Errorf("expected one item left after popping, given %d", q.Len())
+	}
+}
@@ -0,0 +1,376 @@
+package pqueue
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// Codec encodes and decodes values so a PersistentQueue can write them
+// to, and read them back from, its write-ahead log.
+type Codec[V any] interface {
+	Encode(value V) ([]byte, error)
+	Decode(data []byte) (V, error)
+}
+
+type recordKind byte
+
+const (
+	recordEnqueue recordKind = iota + 1
+	recordDequeue
+	recordRemove
+)
+
+// PersistentQueue wraps a Queue with an append-only write-ahead log,
+// so a job scheduler or similar long-running service doesn't lose
+// pending work on crash. Every Enqueue, Dequeue and Remove appends a
+// record carrying a monotonic sequence number and a CRC32 checksum;
+// Recover replays the log to rebuild the in-memory heap, and Compact
+// snapshots the current heap and discards the history that led to it.
+type PersistentQueue[P Ordered, V any] struct {
+	queue *Queue[P, V]
+	codec Codec[V]
+
+	lock sync.Mutex
+	log  io.ReadWriteSeeker
+	seq  uint64
+	// walSeqs tracks the write-ahead-log sequence number each live
+	// item was enqueued under, keyed by the handle Queue.Enqueue
+	// returned for it. It lives here rather than on HeapItem itself
+	// since it's bookkeeping only PersistentQueue needs; the other
+	// queues built on Queue allocate HeapItems too.
+	walSeqs map[*HeapItem[V, P]]uint64
+}
+
+// NewPersistentQueue creates a persistent queue that appends its
+// write-ahead log to log. If log already holds records from a
+// previous run, call Recover before using the queue.
+func NewPersistentQueue[P Ordered, V any](log io.ReadWriteSeeker, codec Codec[V]) *PersistentQueue[P, V] {
+	return &PersistentQueue[P, V]{
+		queue:   New[P, V](0),
+		codec:   codec,
+		log:     log,
+		walSeqs: map[*HeapItem[V, P]]uint64{},
+	}
+}
+
+// OpenPersistentQueueFile opens (creating if necessary) the file at
+// path to use as the write-ahead log for a persistent queue, and
+// recovers any records already in it.
+func OpenPersistentQueueFile[P Ordered, V any](path string, codec Codec[V]) (*PersistentQueue[P, V], error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	pq := NewPersistentQueue[P, V](f, codec)
+	if err := pq.Recover(); err != nil {
+		return nil, err
+	}
+	return pq, nil
+}
+
+// Enqueue puts item on the underlying queue and then appends an ENQ
+// record for it to the log. If the queue refuses the item (full or
+// disposed), nothing is written; if the append fails, the item is
+// taken back off the queue and the error is returned. The log is not
+// synced to stable storage.
+func (pq *PersistentQueue[P, V]) Enqueue(item V, priority P) (*HeapItem[V, P], error) {
+	payload, err := pq.encodeEnqueuePayload(priority, item)
+	if err != nil {
+		return nil, err
+	}
+
+	pq.lock.Lock()
+	defer pq.lock.Unlock()
+
+	hi, err := pq.queue.Enqueue(item, priority)
+	if err != nil {
+		return nil, err
+	}
+	seq := pq.seq + 1
+	if err := pq.append(recordEnqueue, seq, payload); err != nil {
+		pq.queue.Remove(hi)
+		return nil, err
+	}
+	pq.walSeqs[hi] = seq
+	pq.seq = seq
+	return hi, nil
+}
+
+// Dequeue blocks until an item is available, appends a DEQ record
+// referencing its ENQ sequence number, and returns it.
+func (pq *PersistentQueue[P, V]) Dequeue() (item V, priority P, err error) {
+	hi, err := pq.queue.dequeueItem()
+	if err != nil {
+		return
+	}
+
+	pq.lock.Lock()
+	walSeq := pq.walSeqs[hi]
+	delete(pq.walSeqs, hi)
+	seq := pq.seq + 1
+	err = pq.append(recordDequeue, seq, seqPayload(walSeq))
+	if err == nil {
+		pq.seq = seq
+	}
+	pq.lock.Unlock()
+
+	return hi.value, hi.priority, err
+}
+
+// Remove appends a REM record referencing item's ENQ sequence number
+// and removes it from the underlying queue.
+func (pq *PersistentQueue[P, V]) Remove(item *HeapItem[V, P]) error {
+	pq.lock.Lock()
+	seq := pq.seq + 1
+	err := pq.append(recordRemove, seq, seqPayload(pq.walSeqs[item]))
+	if err == nil {
+		pq.seq = seq
+		delete(pq.walSeqs, item)
+	}
+	pq.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	pq.queue.Remove(item)
+	return nil
+}
+
+// Len returns the number of items currently held by the queue.
+func (pq *PersistentQueue[P, V]) Len() int {
+	return pq.queue.Len()
+}
+
+// Recover replays the write-ahead log from the beginning and rebuilds
+// the in-memory heap from it. Call it once, before the queue is used,
+// to resume after a restart.
+func (pq *PersistentQueue[P, V]) Recover() error {
+	pq.lock.Lock()
+	defer pq.lock.Unlock()
+
+	if _, err := pq.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	type pending struct {
+		priority P
+		value    V
+	}
+	live := map[uint64]pending{}
+	var maxSeq uint64
+
+	r := bufio.NewReader(pq.log)
+	for {
+		rec, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if rec.seq > maxSeq {
+			maxSeq = rec.seq
+		}
+		switch rec.kind {
+		case recordEnqueue:
+			priority, value, err := pq.decodeEnqueuePayload(rec.payload)
+			if err != nil {
+				return err
+			}
+			live[rec.seq] = pending{priority: priority, value: value}
+		case recordDequeue, recordRemove:
+			ref, err := parseSeqPayload(rec.payload)
+			if err != nil {
+				return err
+			}
+			delete(live, ref)
+		default:
+			return fmt.Errorf("pqueue: unknown wal record kind %d", rec.kind)
+		}
+	}
+
+	queue := New[P, V](pq.queue.Limit)
+	walSeqs := make(map[*HeapItem[V, P]]uint64, len(live))
+	for seq, e := range live {
+		hi, err := queue.Enqueue(e.value, e.priority)
+		if err != nil {
+			return err
+		}
+		walSeqs[hi] = seq
+	}
+	pq.queue = queue
+	pq.walSeqs = walSeqs
+	pq.seq = maxSeq
+	return nil
+}
+
+// Compact rewrites the log down to a single ENQ record per item still
+// in the queue, dropping the DEQ/REM history that produced the
+// current state, then truncates anything left over from the old log.
+// It returns an error, without modifying the log, if log does not
+// support truncation: otherwise the stale tail left behind would make
+// the very next Recover fail.
+func (pq *PersistentQueue[P, V]) Compact() error {
+	pq.lock.Lock()
+	defer pq.lock.Unlock()
+
+	truncater, ok := pq.log.(interface{ Truncate(int64) error })
+	if !ok {
+		return errors.New("pqueue: log does not support truncation, cannot compact")
+	}
+
+	items := pq.queue.snapshot()
+
+	if _, err := pq.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, hi := range items {
+		payload, err := pq.encodeEnqueuePayload(hi.priority, hi.value)
+		if err != nil {
+			return err
+		}
+		if err := writeRecord(&buf, recordEnqueue, pq.walSeqs[hi], payload); err != nil {
+			return err
+		}
+	}
+	if _, err := pq.log.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return truncater.Truncate(int64(buf.Len()))
+}
+
+func (pq *PersistentQueue[P, V]) append(kind recordKind, seq uint64, payload []byte) error {
+	if _, err := pq.log.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	return writeRecord(pq.log, kind, seq, payload)
+}
+
+// encodeEnqueuePayload lays out a priority, stringified via fmt so any
+// Ordered type round-trips without per-type encoding code, followed by
+// the codec-encoded value.
+func (pq *PersistentQueue[P, V]) encodeEnqueuePayload(priority P, value V) ([]byte, error) {
+	priorityBytes := []byte(fmt.Sprintf("%v", priority))
+	valueBytes, err := pq.codec.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 4, 4+len(priorityBytes)+len(valueBytes))
+	binary.BigEndian.PutUint32(buf, uint32(len(priorityBytes)))
+	buf = append(buf, priorityBytes...)
+	buf = append(buf, valueBytes...)
+	return buf, nil
+}
+
+func (pq *PersistentQueue[P, V]) decodeEnqueuePayload(payload []byte) (priority P, value V, err error) {
+	if len(payload) < 4 {
+		err = errors.New("pqueue: truncated enqueue record")
+		return
+	}
+	n := binary.BigEndian.Uint32(payload[:4])
+	rest := payload[4:]
+	if uint32(len(rest)) < n {
+		err = errors.New("pqueue: truncated enqueue record")
+		return
+	}
+	if priority, err = decodePriority[P](rest[:n]); err != nil {
+		return
+	}
+	value, err = pq.codec.Decode(rest[n:])
+	return
+}
+
+// decodePriority parses the bytes written by encodeEnqueuePayload back
+// into P. fmt.Sscan tokenizes on whitespace, which would silently
+// truncate a ~string priority containing spaces even though its exact
+// length is already known from the record's framing; string-kind
+// priorities are therefore taken verbatim instead of being scanned.
+func decodePriority[P Ordered](data []byte) (P, error) {
+	var priority P
+	if reflect.TypeOf(priority).Kind() == reflect.String {
+		reflect.ValueOf(&priority).Elem().SetString(string(data))
+		return priority, nil
+	}
+	_, err := fmt.Sscan(string(data), &priority)
+	return priority, err
+}
+
+func seqPayload(seq uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, seq)
+	return buf
+}
+
+func parseSeqPayload(payload []byte) (uint64, error) {
+	if len(payload) != 8 {
+		return 0, errors.New("pqueue: malformed wal reference record")
+	}
+	return binary.BigEndian.Uint64(payload), nil
+}
+
+type record struct {
+	kind    recordKind
+	seq     uint64
+	payload []byte
+}
+
+// writeRecord appends kind | seq | len(payload) | payload | crc32 to w.
+func writeRecord(w io.Writer, kind recordKind, seq uint64, payload []byte) error {
+	header := make([]byte, 13)
+	header[0] = byte(kind)
+	binary.BigEndian.PutUint64(header[1:9], seq)
+	binary.BigEndian.PutUint32(header[9:13], uint32(len(payload)))
+
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write(header)
+	_, _ = crc.Write(payload)
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return err
+	}
+	var crcBuf [4]byte
+	binary.BigEndian.PutUint32(crcBuf[:], crc.Sum32())
+	_, err := w.Write(crcBuf[:])
+	return err
+}
+
+func readRecord(r io.Reader) (*record, error) {
+	header := make([]byte, 13)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(header[9:13])
+
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, errors.New("pqueue: truncated wal record")
+	}
+	var crcBuf [4]byte
+	if _, err := io.ReadFull(r, crcBuf[:]); err != nil {
+		return nil, errors.New("pqueue: truncated wal record")
+	}
+
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write(header)
+	_, _ = crc.Write(payload)
+	if crc.Sum32() != binary.BigEndian.Uint32(crcBuf[:]) {
+		return nil, errors.New("pqueue: wal record failed checksum")
+	}
+
+	return &record{
+		kind:    recordKind(header[0]),
+		seq:     binary.BigEndian.Uint64(header[1:9]),
+		payload: payload,
+	}, nil
+}
@@ -0,0 +1,205 @@
+package pqueue
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+// stringCodec is a trivial Codec for the string values these tests use.
+type stringCodec struct{}
+
+func (stringCodec) Encode(value string) ([]byte, error) { return []byte(value), nil }
+func (stringCodec) Decode(data []byte) (string, error)  { return string(data), nil }
+
+func newTestLog(t *testing.T) *os.File {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "pqueue-wal-*")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp wal file: %v", err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+	return f
+}
+
+// untruncatableLog is an io.ReadWriteSeeker backed by an in-memory
+// buffer, deliberately missing a Truncate method, to exercise logs
+// that can't support Compact.
+type untruncatableLog struct {
+	buf    *bytes.Reader
+	data   []byte
+	offset int64
+}
+
+func newUntruncatableLog() *untruncatableLog {
+	return &untruncatableLog{}
+}
+
+func (l *untruncatableLog) Write(p []byte) (int, error) {
+	if int(l.offset)+len(p) > len(l.data) {
+		grown := make([]byte, int(l.offset)+len(p))
+		copy(grown, l.data)
+		l.data = grown
+	}
+	n := copy(l.data[l.offset:], p)
+	l.offset += int64(n)
+	return n, nil
+}
+
+func (l *untruncatableLog) Read(p []byte) (int, error) {
+	if l.offset >= int64(len(l.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, l.data[l.offset:])
+	l.offset += int64(n)
+	return n, nil
+}
+
+func (l *untruncatableLog) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		l.offset = offset
+	case io.SeekCurrent:
+		l.offset += offset
+	case io.SeekEnd:
+		l.offset = int64(len(l.data)) + offset
+	}
+	return l.offset, nil
+}
+
+func logSize(t *testing.T, f *os.File) int64 {
+	t.Helper()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error stating wal file: %v", err)
+	}
+	return info.Size()
+}
+
+func TestPersistentQueueEnqueueDequeue(t *testing.T) {
+	log := newTestLog(t)
+	pq := NewPersistentQueue[int, string](log, stringCodec{})
+
+	_, _ = pq.Enqueue("low", 5)
+	_, _ = pq.Enqueue("high", 1)
+
+	value, priority, err := pq.Dequeue()
+	if err != nil || value != "high" || priority != 1 {
+		t.Fatalf("expected to dequeue %q at priority 1, given %q/%d, err %v", "high", value, priority, err)
+	}
+	if pq.Len() != 1 {
+		t.Errorf("expected 1 item left, given %d", pq.Len())
+	}
+}
+
+func TestPersistentQueueRecover(t *testing.T) {
+	log := newTestLog(t)
+	pq := NewPersistentQueue[int, string](log, stringCodec{})
+
+	_, _ = pq.Enqueue("a", 3)
+	_, _ = pq.Enqueue("b", 1)
+	_, _ = pq.Enqueue("c", 2)
+	if _, _, err := pq.Dequeue(); err != nil { // removes "b"
+		t.Fatalf("unexpected error dequeuing: %v", err)
+	}
+
+	recovered := NewPersistentQueue[int, string](log, stringCodec{})
+	if err := recovered.Recover(); err != nil {
+		t.Fatalf("unexpected error recovering: %v", err)
+	}
+	if recovered.Len() != 2 {
+		t.Fatalf("expected 2 surviving items, given %d", recovered.Len())
+	}
+
+	value, priority, err := recovered.Dequeue()
+	if err != nil || value != "c" || priority != 2 {
+		t.Errorf("expected to recover %q at priority 2 first, given %q/%d, err %v", "c", value, priority, err)
+	}
+}
+
+func TestPersistentQueueEnqueueFailureDoesNotLeakIntoLog(t *testing.T) {
+	log := newTestLog(t)
+	pq := NewPersistentQueue[int, string](log, stringCodec{})
+
+	if _, err := pq.Enqueue("a", 1); err != nil {
+		t.Fatalf("unexpected error enqueuing: %v", err)
+	}
+	pq.queue.Dispose()
+	if _, err := pq.Enqueue("b", 2); err != ErrDisposed {
+		t.Fatalf("expected ErrDisposed, given %v", err)
+	}
+
+	recovered := NewPersistentQueue[int, string](log, stringCodec{})
+	if err := recovered.Recover(); err != nil {
+		t.Fatalf("unexpected error recovering: %v", err)
+	}
+	if recovered.Len() != 1 {
+		t.Errorf("expected the failed enqueue to leave no trace in the log, given %d items", recovered.Len())
+	}
+}
+
+func TestPersistentQueuePriorityWithSpacesRoundTrips(t *testing.T) {
+	log := newTestLog(t)
+	pq := NewPersistentQueue[string, string](log, stringCodec{})
+
+	const priority = "priority with spaces"
+	_, _ = pq.Enqueue("item", priority)
+
+	recovered := NewPersistentQueue[string, string](log, stringCodec{})
+	if err := recovered.Recover(); err != nil {
+		t.Fatalf("unexpected error recovering: %v", err)
+	}
+	_, gotPriority, err := recovered.Dequeue()
+	if err != nil || gotPriority != priority {
+		t.Errorf("expected priority %q to round-trip, given %q, err %v", priority, gotPriority, err)
+	}
+}
+
+func TestPersistentQueueCompactRequiresTruncation(t *testing.T) {
+	log := newUntruncatableLog()
+	pq := NewPersistentQueue[int, string](log, stringCodec{})
+
+	_, _ = pq.Enqueue("a", 1)
+
+	before := append([]byte(nil), log.data...)
+	if err := pq.Compact(); err == nil {
+		t.Fatalf("expected Compact to fail on a log that can't be truncated")
+	}
+	if !bytes.Equal(log.data, before) {
+		t.Errorf("expected Compact to leave the log untouched when it cannot truncate")
+	}
+
+	recovered := NewPersistentQueue[int, string](log, stringCodec{})
+	if err := recovered.Recover(); err != nil {
+		t.Fatalf("unexpected error recovering after a failed compact: %v", err)
+	}
+	if recovered.Len() != 1 {
+		t.Errorf("expected 1 item to survive, given %d", recovered.Len())
+	}
+}
+
+func TestPersistentQueueCompact(t *testing.T) {
+	log := newTestLog(t)
+	pq := NewPersistentQueue[int, string](log, stringCodec{})
+
+	_, _ = pq.Enqueue("a", 1)
+	_, _ = pq.Enqueue("b", 2)
+	_, _, _ = pq.Dequeue() // produces a DEQ record for "a"
+
+	beforeCompact := logSize(t, log)
+	if err := pq.Compact(); err != nil {
+		t.Fatalf("unexpected error compacting: %v", err)
+	}
+	if afterCompact := logSize(t, log); afterCompact >= beforeCompact {
+		t.Errorf("expected Compact to shrink the log, was %d bytes, now %d", beforeCompact, afterCompact)
+	}
+
+	recovered := NewPersistentQueue[int, string](log, stringCodec{})
+	if err := recovered.Recover(); err != nil {
+		t.Fatalf("unexpected error recovering after compact: %v", err)
+	}
+	if recovered.Len() != 1 {
+		t.Errorf("expected 1 item to survive compaction, given %d", recovered.Len())
+	}
+}
@@ -6,160 +6,303 @@ import (
 	"container/heap"
 	"errors"
 	"sync"
+	"time"
 )
 
-// Only items implementing this interface can be enqueued
-// on the priority queue.
-type Interface interface {
-	Less(other interface{}) bool
-	Index() int
-	UpdateIndex(i int)
+// ErrDisposed is returned by queue operations once Dispose has been
+// called; the queue can no longer be used afterwards.
+var ErrDisposed = errors.New("queue disposed")
+
+// ErrTimeout is returned by PollDequeue when no item becomes available
+// before the given timeout elapses.
+var ErrTimeout = errors.New("queue dequeue timed out")
+
+// Ordered is the set of types a queue may use as a priority: anything
+// that supports the < operator.
+type Ordered interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64 | ~string
+}
+
+// HeapItem wraps a user value together with its priority and its
+// current position in the backing heap. A *HeapItem is returned by
+// Enqueue as a handle that can later be passed to Remove.
+type HeapItem[V any, P Ordered] struct {
+	value    V
+	priority P
+	index    int
 }
 
+// Value returns the enqueued value.
+func (i *HeapItem[V, P]) Value() V { return i.value }
+
+// Priority returns the priority the item was enqueued with.
+func (i *HeapItem[V, P]) Priority() P { return i.priority }
+
 // Queue is a threadsafe priority queue exchange. Here's
 // a trivial example of usage:
 //
-//     q := pqueue.New(0)
+//     q := pqueue.New[int, string](0)
 //     go func() {
 //         for {
-//             task := q.Dequeue()
-//             println(task.(*CustomTask).Name)
+//             task, _, _ := q.Dequeue()
+//             println(task)
 //         }
 //     }()
-//     for i := 0; i < 100; i := 1 {
-//         task := CustomTask{Name: "foo", priority: rand.Intn(10)}
-//         q.Enqueue(&task)
+//     for i := 0; i < 100; i += 1 {
+//         _, _ = q.Enqueue("foo", rand.Intn(10))
 //     }
 //
-type Queue struct {
-	Limit int
-	items *sorter
-	cond  *sync.Cond
+type Queue[P Ordered, V any] struct {
+	Limit    int
+	items    *sorter[V, P]
+	cond     *sync.Cond
+	disposed bool
 }
 
 // New creates and initializes a new priority queue, taking
 // a limit as a parameter. If 0 given, then queue will be
 // unlimited.
-func New(max int) (q *Queue) {
+func New[P Ordered, V any](max int) (q *Queue[P, V]) {
 	var locker sync.Mutex
-	q = &Queue{Limit: max}
-	q.items = new(sorter)
+	q = &Queue[P, V]{Limit: max}
+	q.items = new(sorter[V, P])
 	q.cond = sync.NewCond(&locker)
 	heap.Init(q.items)
 	return
 }
 
-// Enqueue puts given item to the queue.
-func (q *Queue) Enqueue(item Interface) error {
+// Enqueue puts the given item on the queue under the given priority
+// and returns a handle that can later be passed to Remove.
+func (q *Queue[P, V]) Enqueue(item V, priority P) (*HeapItem[V, P], error) {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
-	if q.Limit > 0 && q.Len() >= q.Limit {
-		return errors.New("queue limit reached")
+	if q.disposed {
+		return nil, ErrDisposed
 	}
-	heap.Push(q.items, item)
+	if q.Limit > 0 && q.items.Len() >= q.Limit {
+		return nil, errors.New("queue limit reached")
+	}
+	hi := &HeapItem[V, P]{value: item, priority: priority}
+	heap.Push(q.items, hi)
 	q.cond.Signal()
-	return nil
+	return hi, nil
+}
+
+// Dequeue takes the item with the lowest priority from the queue. If
+// queue is empty then blocks waiting for at least one item, returning
+// ErrDisposed if the queue is disposed while waiting or beforehand.
+func (q *Queue[P, V]) Dequeue() (item V, priority P, err error) {
+	hi, err := q.dequeueItem()
+	if err != nil {
+		return
+	}
+	return hi.value, hi.priority, nil
 }
 
-// Dequeue takes an item from the queue. If queue is empty
-// then should block waiting for at least one item.
-func (q *Queue) Dequeue() Interface {
+// dequeueItem behaves like Dequeue but returns the popped HeapItem
+// itself rather than unpacking it, for package-internal callers (such
+// as PersistentQueue) that need to correlate the popped item with
+// bookkeeping keyed by its handle without reaching into the fields
+// Dequeue's wait/pop loop touches under the lock.
+func (q *Queue[P, V]) dequeueItem() (*HeapItem[V, P], error) {
 	q.cond.L.Lock()
-start:
-	x := heap.Pop(q.items)
-	if x == nil {
+	defer q.cond.L.Unlock()
+	for q.items.Len() == 0 && !q.disposed {
+		q.cond.Wait()
+	}
+	if q.disposed {
+		return nil, ErrDisposed
+	}
+	return heap.Pop(q.items).(*HeapItem[V, P]), nil
+}
+
+// snapshot returns a copy of the queue's current items, in no
+// particular order, for package-internal callers that need to inspect
+// them without removing them (such as PersistentQueue.Compact).
+func (q *Queue[P, V]) snapshot() []*HeapItem[V, P] {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	items := make([]*HeapItem[V, P], q.items.Len())
+	copy(items, *q.items)
+	return items
+}
+
+// PollDequeue behaves like Dequeue but gives up and returns ErrTimeout
+// if no item becomes available before timeout elapses.
+func (q *Queue[P, V]) PollDequeue(timeout time.Duration) (item V, priority P, err error) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	deadline := time.Now().Add(timeout)
+	for q.items.Len() == 0 && !q.disposed {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			err = ErrTimeout
+			return
+		}
+		timer := time.AfterFunc(remaining, func() {
+			q.cond.L.Lock()
+			q.cond.Broadcast()
+			q.cond.L.Unlock()
+		})
 		q.cond.Wait()
-		goto start
+		timer.Stop()
+	}
+	if q.disposed {
+		err = ErrDisposed
+		return
+	}
+	hi := heap.Pop(q.items).(*HeapItem[V, P])
+	return hi.value, hi.priority, nil
+}
+
+// DequeueBatch drains up to max ready items from the queue in a single
+// lock acquisition, which is cheaper than calling Dequeue max times
+// for consumers that can process several items at once. It never
+// blocks: if fewer than max items are available, it returns what it
+// has, including none at all. Once the queue has been disposed it
+// always returns nil, same as Enqueue, Dequeue and PollDequeue report
+// ErrDisposed.
+func (q *Queue[P, V]) DequeueBatch(max int) []*HeapItem[V, P] {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	if q.disposed {
+		return nil
+	}
+
+	n := q.items.Len()
+	if max > 0 && max < n {
+		n = max
+	}
+	batch := make([]*HeapItem[V, P], 0, n)
+	for i := 0; i < n; i++ {
+		batch = append(batch, heap.Pop(q.items).(*HeapItem[V, P]))
 	}
-	q.cond.L.Unlock()
-	return x.(Interface)
+	return batch
 }
 
-func (q *Queue) Front() Interface {
+// Dispose shuts the queue down, waking every blocked Dequeue or
+// PollDequeue call with ErrDisposed. Further Enqueue, Dequeue and
+// PollDequeue calls also return ErrDisposed; DequeueBatch, which
+// reports no error, returns nil instead.
+func (q *Queue[P, V]) Dispose() {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
-	return q.items.Front().(Interface)
+	if q.disposed {
+		return
+	}
+	q.disposed = true
+	q.cond.Broadcast()
+}
+
+// Front returns the item with the lowest priority without removing it.
+func (q *Queue[P, V]) Front() (V, P) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if hi := q.items.Front(); hi != nil {
+		return hi.value, hi.priority
+	}
+	var zeroV V
+	var zeroP P
+	return zeroV, zeroP
 }
 
-func (q *Queue) Back() Interface {
+// Back returns the item stored in the last slot of the backing heap
+// without removing it. The heap is only partially ordered, so this is
+// not necessarily the item with the highest priority.
+func (q *Queue[P, V]) Back() (V, P) {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
-	return q.items.Back().(Interface)
+	if hi := q.items.Back(); hi != nil {
+		return hi.value, hi.priority
+	}
+	var zeroV V
+	var zeroP P
+	return zeroV, zeroP
 }
 
-// Remove removes the element at index i from the heap.
-// The complexity is O(log n) where n = h.Len().
-func (q *Queue) Remove(item Interface) {
+// Remove removes the item referenced by the handle returned from
+// Enqueue. The complexity is O(log n) where n = h.Len().
+func (q *Queue[P, V]) Remove(item *HeapItem[V, P]) {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
-	q.items.Remove(item.Index())
+	q.items.Remove(item.index)
 }
 
 // Safely changes enqueued items limit. When limit is set
 // to 0, then queue is unlimited.
-func (q *Queue) ChangeLimit(newLimit int) {
+func (q *Queue[P, V]) ChangeLimit(newLimit int) {
 	q.cond.L.Lock()
 	defer q.cond.L.Unlock()
 	q.Limit = newLimit
 }
 
 // Len returns number of enqueued elemnents.
-func (q *Queue) Len() int {
+func (q *Queue[P, V]) Len() int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
 	return q.items.Len()
 }
 
-type sorter []Interface
+// IsEmpty reports whether the queue currently holds no items.
+func (q *Queue[P, V]) IsEmpty() bool {
+	return q.Len() == 0
+}
+
+type sorter[V any, P Ordered] []*HeapItem[V, P]
 
-func (s *sorter) Push(i interface{}) {
-	n := len(*s)
-	item, ok := i.(Interface)
+func (s *sorter[V, P]) Push(i interface{}) {
+	item, ok := i.(*HeapItem[V, P])
 	if !ok {
 		return
 	}
-	item.UpdateIndex(n)
+	item.index = len(*s)
 	*s = append(*s, item)
 }
 
-func (s *sorter) Pop() interface{} {
+func (s *sorter[V, P]) Pop() interface{} {
 	old := *s
 	n := len(old)
 	if n > 0 {
 		item := old[n-1]
-		old[n-1] = nil       // avoid memory leak
-		item.UpdateIndex(-1) // for safety
+		old[n-1] = nil  // avoid memory leak
+		item.index = -1 // for safety
 		*s = old[0 : n-1]
 		return item
 	}
 	return nil
 }
 
-func (s *sorter) Remove(i int) {
+func (s *sorter[V, P]) Remove(i int) {
 	heap.Remove(s, i)
 }
 
-func (s *sorter) Front() interface{} {
+func (s sorter[V, P]) Front() *HeapItem[V, P] {
 	if s.Len() > 0 {
-		return (*s)[0]
+		return s[0]
 	}
 	return nil
 }
 
-func (s *sorter) Back() interface{} {
+func (s sorter[V, P]) Back() *HeapItem[V, P] {
 	n := s.Len()
 	if n > 0 {
-		return (*s)[n-1]
+		return s[n-1]
 	}
 	return nil
 }
 
-func (s sorter) Len() int { return len(s) }
+func (s sorter[V, P]) Len() int { return len(s) }
 
-func (s sorter) Less(i, j int) bool { return s[i].Less(s[j]) }
+func (s sorter[V, P]) Less(i, j int) bool { return s[i].priority < s[j].priority }
 
-func (s sorter) Swap(i, j int) {
+func (s sorter[V, P]) Swap(i, j int) {
 	if s.Len() > 0 {
 		s[i], s[j] = s[j], s[i]
-		s[i].UpdateIndex(i)
-		s[j].UpdateIndex(j)
+		s[i].index = i
+		s[j].index = j
 	}
 }
@@ -8,37 +8,25 @@ import (
 	"time"
 )
 
-type DummyTask struct {
-	priority int
-}
-
-func NewDummyTask(p int) *DummyTask {
-	return &DummyTask{priority: p}
-}
-
-func (dt *DummyTask) Less(other interface{}) bool {
-	return dt.priority < other.(*DummyTask).priority
-}
-
 func TestNewQueue(t *testing.T) {
-	q := New(100)
+	q := New[int, string](100)
 	if q.Limit != 100 {
 		t.Errorf("expected to set queue limit on create")
 	}
 }
 
 func TestEnqueueAndDequeue(t *testing.T) {
-	q := New(0)
+	q := New[int, string](0)
 	for _, x := range []int{1, 3, 4, 2, 7, 3} {
-		_ = q.Enqueue(NewDummyTask(x))
+		_, _ = q.Enqueue("task", x)
 	}
 	if q.Len() != 6 {
 		t.Errorf("expected to enqueue all the items")
 	}
 	for _, x := range []int{1, 2, 3, 3, 4, 7} {
-		task := q.Dequeue().(*DummyTask)
-		if task.priority != x {
-			t.Errorf("expected priority to be %d, given %d", x, task.priority)
+		_, priority, _ := q.Dequeue()
+		if priority != x {
+			t.Errorf("expected priority to be %d, given %d", x, priority)
 		}
 	}
 	if q.Len() != 0 {
@@ -47,28 +35,28 @@ func TestEnqueueAndDequeue(t *testing.T) {
 }
 
 func TestWaitForDequeue(t *testing.T) {
-	q := New(0)
+	q := New[int, string](0)
 	dequeued := false
 	go func() {
-		if q.Dequeue() != nil {
+		if _, priority, _ := q.Dequeue(); priority == 1 {
 			dequeued = true
 		}
 	}()
 	<-time.After(1e9)
-	_ = q.Enqueue(NewDummyTask(1))
-	<-time.After(1e2)
+	_, _ = q.Enqueue("task", 1)
+	<-time.After(1e8)
 	if !dequeued {
 		t.Errorf("expected to wait for dequeue")
 	}
 }
 
 func TestIsEmpty(t *testing.T) {
-	q := New(0)
+	q := New[int, string](0)
 	if !q.IsEmpty() {
 		t.Errorf("expected queue to be empty")
 	}
 	for _, x := range []int{1, 2, 3, 4} {
-		_ = q.Enqueue(NewDummyTask(x))
+		_, _ = q.Enqueue("task", x)
 	}
 	if q.IsEmpty() {
 		t.Errorf("expected queue to not be empty")
@@ -76,10 +64,10 @@ func TestIsEmpty(t *testing.T) {
 }
 
 func TestLimit(t *testing.T) {
-	q := New(10)
+	q := New[int, string](10)
 	var err error
 	for i := 0; i < 20; i += 1 {
-		err = q.Enqueue(NewDummyTask(i))
+		_, err = q.Enqueue("task", i)
 	}
 	if err == nil || err.Error() != "queue limit reached" {
 		t.Errorf("expected to reach queue limit")
@@ -89,24 +77,105 @@ func TestLimit(t *testing.T) {
 	}
 }
 
+func TestRemove(t *testing.T) {
+	q := New[int, string](0)
+	hi, _ := q.Enqueue("remove-me", 5)
+	_, _ = q.Enqueue("keep-me", 1)
+	q.Remove(hi)
+	if q.Len() != 1 {
+		t.Errorf("expected to remove a single item")
+	}
+	value, _, _ := q.Dequeue()
+	if value != "keep-me" {
+		t.Errorf("expected remaining item to be %q, given %q", "keep-me", value)
+	}
+}
+
+func TestPollDequeueTimeout(t *testing.T) {
+	q := New[int, string](0)
+	if _, _, err := q.PollDequeue(10 * time.Millisecond); err != ErrTimeout {
+		t.Errorf("expected ErrTimeout on an empty queue, given %v", err)
+	}
+
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		_, _ = q.Enqueue("task", 1)
+	}()
+	value, _, err := q.PollDequeue(time.Second)
+	if err != nil || value != "task" {
+		t.Errorf("expected to dequeue %q, given %q, err %v", "task", value, err)
+	}
+}
+
+func TestDequeueBatch(t *testing.T) {
+	q := New[int, string](0)
+	for _, x := range []int{4, 1, 3, 2} {
+		_, _ = q.Enqueue("task", x)
+	}
+	batch := q.DequeueBatch(2)
+	if len(batch) != 2 || batch[0].Priority() != 1 || batch[1].Priority() != 2 {
+		t.Errorf("expected the 2 lowest-priority items, given %v", batch)
+	}
+	if q.Len() != 2 {
+		t.Errorf("expected 2 items left, given %d", q.Len())
+	}
+	if batch = q.DequeueBatch(10); len(batch) != 2 {
+		t.Errorf("expected DequeueBatch to cap at the items available, given %d", len(batch))
+	}
+}
+
+func TestDispose(t *testing.T) {
+	q := New[int, string](0)
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := q.Dequeue()
+		done <- err
+	}()
+	<-time.After(10 * time.Millisecond)
+	q.Dispose()
+
+	select {
+	case err := <-done:
+		if err != ErrDisposed {
+			t.Errorf("expected ErrDisposed, given %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected Dispose to wake a blocked Dequeue call")
+	}
+
+	if _, err := q.Enqueue("task", 1); err != ErrDisposed {
+		t.Errorf("expected Enqueue on a disposed queue to fail, given %v", err)
+	}
+}
+
+func TestDequeueBatchAfterDispose(t *testing.T) {
+	q := New[int, string](0)
+	_, _ = q.Enqueue("task", 1)
+	q.Dispose()
+
+	if batch := q.DequeueBatch(10); batch != nil {
+		t.Errorf("expected DequeueBatch on a disposed queue to return nil, given %v", batch)
+	}
+}
+
 func BenchmarkEnqueue(b *testing.B) {
 	b.StopTimer()
-	q := New(0)
+	q := New[int, int](0)
 	b.StartTimer()
 	for i := 0; i < 200000; i += 1 {
-		_ = q.Enqueue(NewDummyTask(rand.Intn(10)))
+		_, _ = q.Enqueue(i, rand.Intn(10))
 	}
 }
 
 func BenchmarkMultiEnqueue(b *testing.B) {
 	b.StopTimer()
-	q := New(0)
+	q := New[int, int](0)
 	done := make(chan bool)
 	b.StartTimer()
 	for i := 0; i < 4; i += 1 {
 		go func() {
 			for j := 0; j < 50000; j += 1 {
-				_ = q.Enqueue(NewDummyTask(rand.Intn(10)))
+				_, _ = q.Enqueue(j, rand.Intn(10))
 			}
 			done <- true
 		}()
@@ -118,17 +187,17 @@ func BenchmarkMultiEnqueue(b *testing.B) {
 
 func BenchmarkDequeue(b *testing.B) {
 	b.StopTimer()
-	q := New(0)
+	q := New[int, int](0)
 	b.StartTimer()
 	go func() {
 		for i := 0; i < 200000; i += 1 {
-			_ = q.Enqueue(NewDummyTask(rand.Intn(10)))
+			_, _ = q.Enqueue(i, rand.Intn(10))
 		}
-		_ = q.Enqueue(NewDummyTask(1000000))
+		_, _ = q.Enqueue(1000000, 1000000)
 	}()
 	for {
-		task := q.Dequeue().(*DummyTask)
-		if task.priority == 1000000 {
+		_, priority, _ := q.Dequeue()
+		if priority == 1000000 {
 			break
 		}
 	}
@@ -136,20 +205,20 @@ func BenchmarkDequeue(b *testing.B) {
 
 func BenchmarkMultiDequeue(b *testing.B) {
 	b.StopTimer()
-	q := New(0)
+	q := New[int, int](0)
 	done := make(chan bool)
 	b.StartTimer()
 	go func() {
 		for i := 0; i < 200000; i += 1 {
-			_ = q.Enqueue(NewDummyTask(rand.Intn(10)))
+			_, _ = q.Enqueue(i, rand.Intn(10))
 		}
-		_ = q.Enqueue(NewDummyTask(1000000))
+		_, _ = q.Enqueue(1000000, 1000000)
 	}()
 	for i := 0; i < 4; i += 1 {
 		go func() {
 			for {
-				task := q.Dequeue().(*DummyTask)
-				if task.priority == 1000000 {
+				_, priority, _ := q.Dequeue()
+				if priority == 1000000 {
 					done <- true
 					break
 				}
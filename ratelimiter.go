@@ -0,0 +1,124 @@
+package pqueue
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter controls how long an item must wait before it becomes
+// eligible to be enqueued again, letting callers implement retry with
+// backoff without reimplementing the bookkeeping per project.
+type RateLimiter[V comparable] interface {
+	// When returns how long the given item should wait before being
+	// made available again.
+	When(item V) time.Duration
+	// Forget clears any retry history tracked for the item, as if it
+	// had never failed.
+	Forget(item V)
+	// NumRequeues returns how many times When has been called for
+	// the given item since it was last Forgotten.
+	NumRequeues(item V) int
+}
+
+// ExponentialBackoffLimiter doubles its delay on every successive
+// failure of the same item, up to maxDelay.
+type ExponentialBackoffLimiter[V comparable] struct {
+	baseDelay time.Duration
+	maxDelay  time.Duration
+
+	lock     sync.Mutex
+	failures map[V]int
+}
+
+// NewExponentialBackoffLimiter creates a limiter that returns
+// baseDelay * 2^failures, capped at maxDelay.
+func NewExponentialBackoffLimiter[V comparable](baseDelay, maxDelay time.Duration) *ExponentialBackoffLimiter[V] {
+	return &ExponentialBackoffLimiter[V]{
+		baseDelay: baseDelay,
+		maxDelay:  maxDelay,
+		failures:  map[V]int{},
+	}
+}
+
+func (r *ExponentialBackoffLimiter[V]) When(item V) time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	exp := r.failures[item]
+	r.failures[item] = exp + 1
+
+	delay := float64(r.baseDelay) * math.Pow(2, float64(exp))
+	if delay > float64(r.maxDelay) {
+		return r.maxDelay
+	}
+	return time.Duration(delay)
+}
+
+func (r *ExponentialBackoffLimiter[V]) Forget(item V) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.failures, item)
+}
+
+func (r *ExponentialBackoffLimiter[V]) NumRequeues(item V) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.failures[item]
+}
+
+// TokenBucketLimiter spreads items out at a configured rate, allowing
+// short bursts up to burst tokens before it starts imposing a delay.
+type TokenBucketLimiter[V comparable] struct {
+	qps   float64
+	burst int
+
+	lock     sync.Mutex
+	tokens   float64
+	last     time.Time
+	requeues map[V]int
+}
+
+// NewTokenBucketLimiter creates a limiter allowing qps items per
+// second on average, with bursts of up to burst items.
+func NewTokenBucketLimiter[V comparable](qps float64, burst int) *TokenBucketLimiter[V] {
+	return &TokenBucketLimiter[V]{
+		qps:      qps,
+		burst:    burst,
+		tokens:   float64(burst),
+		last:     time.Now(),
+		requeues: map[V]int{},
+	}
+}
+
+func (r *TokenBucketLimiter[V]) When(item V) time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.requeues[item]++
+
+	now := time.Now()
+	r.tokens += now.Sub(r.last).Seconds() * r.qps
+	if r.tokens > float64(r.burst) {
+		r.tokens = float64(r.burst)
+	}
+	r.last = now
+
+	r.tokens--
+	if r.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-r.tokens / r.qps * float64(time.Second))
+}
+
+func (r *TokenBucketLimiter[V]) Forget(item V) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.requeues, item)
+}
+
+func (r *TokenBucketLimiter[V]) NumRequeues(item V) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.requeues[item]
+}
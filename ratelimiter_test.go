@@ -0,0 +1,43 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoffLimiter(t *testing.T) {
+	limiter := NewExponentialBackoffLimiter[string](10*time.Millisecond, 100*time.Millisecond)
+	want := []time.Duration{10, 20, 40, 80, 100, 100}
+	for i, w := range want {
+		if got := limiter.When("item"); got != w*time.Millisecond {
+			t.Errorf("attempt %d: expected delay %s, given %s", i, w*time.Millisecond, got)
+		}
+	}
+	if n := limiter.NumRequeues("item"); n != len(want) {
+		t.Errorf("expected %d requeues, given %d", len(want), n)
+	}
+	limiter.Forget("item")
+	if n := limiter.NumRequeues("item"); n != 0 {
+		t.Errorf("expected requeues to reset after Forget, given %d", n)
+	}
+	if got := limiter.When("item"); got != 10*time.Millisecond {
+		t.Errorf("expected delay to restart at the base delay, given %s", got)
+	}
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	limiter := NewTokenBucketLimiter[string](10, 1)
+	if got := limiter.When("item"); got != 0 {
+		t.Errorf("expected the first request within burst to incur no delay, given %s", got)
+	}
+	if got := limiter.When("item"); got <= 0 {
+		t.Errorf("expected a request beyond burst to be delayed")
+	}
+	if n := limiter.NumRequeues("item"); n != 2 {
+		t.Errorf("expected 2 requeues, given %d", n)
+	}
+	limiter.Forget("item")
+	if n := limiter.NumRequeues("item"); n != 0 {
+		t.Errorf("expected requeues to reset after Forget, given %d", n)
+	}
+}
@@ -0,0 +1,105 @@
+package pqueue
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimitingQueue composes a DelayQueue with a RateLimiter so
+// repeatedly failing items are retried with backoff instead of being
+// made available again immediately. It is modeled on Kubernetes'
+// workqueue.RateLimitingInterface.
+type RateLimitingQueue[V comparable] struct {
+	delayQueue *DelayQueue[V]
+	limiter    RateLimiter[V]
+
+	lock       sync.Mutex
+	dirty      map[V]time.Duration
+	processing map[V]struct{}
+}
+
+// NewRateLimitingQueue creates a rate limiting queue backed by the
+// given limiter.
+func NewRateLimitingQueue[V comparable](limiter RateLimiter[V]) *RateLimitingQueue[V] {
+	return &RateLimitingQueue[V]{
+		delayQueue: NewDelayQueue[V](),
+		limiter:    limiter,
+		dirty:      map[V]time.Duration{},
+		processing: map[V]struct{}{},
+	}
+}
+
+// AddRateLimited inserts item into the queue after the delay given by
+// the limiter for it has elapsed. An item that is already waiting to
+// be processed is not scheduled again; an item that is currently being
+// processed is instead marked dirty and left for Done to re-enqueue
+// once processing finishes, so a single key is never handed out to two
+// concurrent Dequeue callers at once.
+func (q *RateLimitingQueue[V]) AddRateLimited(item V) {
+	delay := q.limiter.When(item)
+
+	q.lock.Lock()
+	if _, ok := q.dirty[item]; ok {
+		q.lock.Unlock()
+		return
+	}
+	q.dirty[item] = delay
+	_, busy := q.processing[item]
+	q.lock.Unlock()
+
+	if busy {
+		return
+	}
+	q.delayQueue.AddAfter(item, delay)
+}
+
+// Dequeue blocks until an item is ready and returns it, marking it as
+// being processed. It returns ok == false once the queue is stopped.
+func (q *RateLimitingQueue[V]) Dequeue() (item V, ok bool) {
+	item, ok = q.delayQueue.Dequeue()
+	if !ok {
+		return
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delete(q.dirty, item)
+	q.processing[item] = struct{}{}
+	return item, true
+}
+
+// Done marks item as finished processing. If it was marked dirty by an
+// AddRateLimited call made while it was being processed, it is now
+// re-enqueued with the delay that call computed. Callers call Forget
+// themselves once an item no longer needs to be retried.
+func (q *RateLimitingQueue[V]) Done(item V) {
+	q.lock.Lock()
+	delete(q.processing, item)
+	delay, stillDirty := q.dirty[item]
+	q.lock.Unlock()
+
+	if stillDirty {
+		q.delayQueue.AddAfter(item, delay)
+	}
+}
+
+// Forget clears item's retry history so the next AddRateLimited call
+// treats it as if it had never failed before.
+func (q *RateLimitingQueue[V]) Forget(item V) {
+	q.limiter.Forget(item)
+}
+
+// NumRequeues returns how many times item has been added via
+// AddRateLimited since it was last Forgotten.
+func (q *RateLimitingQueue[V]) NumRequeues(item V) int {
+	return q.limiter.NumRequeues(item)
+}
+
+// Len returns the number of items currently scheduled, ready or not.
+func (q *RateLimitingQueue[V]) Len() int {
+	return q.delayQueue.Len()
+}
+
+// Stop shuts the queue down, waking any blocked Dequeue call.
+func (q *RateLimitingQueue[V]) Stop() {
+	q.delayQueue.Stop()
+}
@@ -0,0 +1,71 @@
+package pqueue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitingQueueBackoff(t *testing.T) {
+	q := NewRateLimitingQueue[string](NewExponentialBackoffLimiter[string](10*time.Millisecond, time.Second))
+	start := time.Now()
+	q.AddRateLimited("item")
+	item, ok := q.Dequeue()
+	if !ok || item != "item" {
+		t.Fatalf("expected to dequeue %q, given %q", "item", item)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("expected AddRateLimited to respect the limiter's delay, waited %s", elapsed)
+	}
+	q.Done(item)
+	if n := q.NumRequeues(item); n != 1 {
+		t.Errorf("expected 1 requeue, given %d", n)
+	}
+	q.Forget(item)
+	if n := q.NumRequeues(item); n != 0 {
+		t.Errorf("expected requeues to reset after Forget, given %d", n)
+	}
+}
+
+func TestRateLimitingQueueCoalescesDirtyAdds(t *testing.T) {
+	q := NewRateLimitingQueue[string](NewExponentialBackoffLimiter[string](time.Hour, time.Hour))
+	q.AddRateLimited("item")
+	q.AddRateLimited("item")
+	if q.Len() != 1 {
+		t.Errorf("expected duplicate adds of the same pending item to coalesce, given %d entries", q.Len())
+	}
+}
+
+func TestRateLimitingQueueDefersReAddUntilDone(t *testing.T) {
+	q := NewRateLimitingQueue[string](NewExponentialBackoffLimiter[string](0, 0))
+
+	q.AddRateLimited("item")
+	item, ok := q.Dequeue()
+	if !ok || item != "item" {
+		t.Fatalf("expected to dequeue %q, given %q", "item", item)
+	}
+
+	// re-added while still being processed: must not be handed out to
+	// a second Dequeue call until Done is called
+	q.AddRateLimited(item)
+
+	done := make(chan bool, 1)
+	go func() {
+		_, ok := q.Dequeue()
+		done <- ok
+	}()
+	select {
+	case <-done:
+		t.Fatalf("expected the re-added item not to be delivered while still processing")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	q.Done(item)
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Errorf("expected the deferred re-add to be delivered after Done")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected Done to re-enqueue the item marked dirty during processing")
+	}
+}